@@ -0,0 +1,80 @@
+package yqlib
+
+import (
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func encodeDocument(t *testing.T, doc *yaml.Node) string {
+	t.Helper()
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		t.Fatalf("failed to encode document: %v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("failed to close encoder: %v", err)
+	}
+	return buf.String()
+}
+
+func TestUpdateByDefaultPreservesCommentsAndStyle(t *testing.T) {
+	input := "# a head comment\nfoo: bar # a line comment\nbaz: qux\n"
+	doc := parseDocument(t, input)
+	nav := NewDataNavigator(testLogger())
+
+	if err := nav.Update(doc, []string{"foo"}, yaml.Node{Kind: yaml.ScalarNode, Value: "hello", Tag: "!!str"}); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	want := "# a head comment\nfoo: hello # a line comment\nbaz: qux\n"
+	got := encodeDocument(t, doc)
+	if got != want {
+		t.Fatalf("Update clobbered a comment on round trip:\n got:  %q\n want: %q", got, want)
+	}
+}
+
+func TestUpdateWithCanOptOutOfPreservingComments(t *testing.T) {
+	input := "foo: bar # a line comment\n"
+	doc := parseDocument(t, input)
+	nav := NewDataNavigator(testLogger())
+
+	opts := NewUpdateOptions()
+	opts.PreserveLineComment = false
+	change := yaml.Node{Kind: yaml.ScalarNode, Value: "hello", Tag: "!!str"}
+	if err := nav.UpdateWith(doc, []string{"foo"}, change, opts); err != nil {
+		t.Fatalf("UpdateWith returned error: %v", err)
+	}
+
+	want := "foo: hello\n"
+	got := encodeDocument(t, doc)
+	if got != want {
+		t.Fatalf("UpdateWith with PreserveLineComment=false should drop the comment:\n got:  %q\n want: %q", got, want)
+	}
+}
+
+func TestSetCommentAndGetComments(t *testing.T) {
+	doc := parseDocument(t, "foo: bar\n")
+	nav := NewDataNavigator(testLogger())
+
+	if err := nav.SetComment(doc, []string{"foo"}, HeadComment, "set via SetComment"); err != nil {
+		t.Fatalf("SetComment returned error: %v", err)
+	}
+
+	comments, err := nav.GetComments(doc, []string{"foo"})
+	if err != nil {
+		t.Fatalf("GetComments returned error: %v", err)
+	}
+	if comments[HeadComment] != "set via SetComment" {
+		t.Fatalf("expected GetComments to see the comment SetComment wrote, got %+v", comments)
+	}
+
+	want := "# set via SetComment\nfoo: bar\n"
+	got := encodeDocument(t, doc)
+	if got != want {
+		t.Fatalf("SetComment didn't round trip:\n got:  %q\n want: %q", got, want)
+	}
+}
@@ -2,8 +2,8 @@ package yqlib
 
 import (
 	"bytes"
+	"fmt"
 	"strconv"
-	"strings"
 
 	logging "gopkg.in/op/go-logging.v1"
 	yaml "gopkg.in/yaml.v3"
@@ -13,11 +13,101 @@ type DataNavigator interface {
 	DebugNode(node *yaml.Node)
 	Get(rootNode *yaml.Node, path []string) (*yaml.Node, error)
 	Update(rootNode *yaml.Node, path []string, changesToApply yaml.Node) error
+	UpdateWithAliasBehaviour(rootNode *yaml.Node, path []string, changesToApply yaml.Node, aliasBehaviour AliasBehaviour) error
+	// UpdateWith is Update with full control over what gets overwritten: by
+	// default it preserves the matched node's comments and style rather than
+	// clobbering them with whatever changesToApply happens to carry.
+	UpdateWith(rootNode *yaml.Node, path []string, changesToApply yaml.Node, opts UpdateOptions) error
 	Delete(rootNode *yaml.Node, path []string) error
+	// SetComment and GetComments let callers script comment edits directly,
+	// without going through Update at all.
+	SetComment(rootNode *yaml.Node, path []string, position CommentPosition, text string) error
+	GetComments(rootNode *yaml.Node, path []string) (map[CommentPosition]string, error)
+	// GetByPath, UpdateByPath and DeleteByPath take a single path expression
+	// (parsed by PathParser) instead of a []string, unlocking `..` recursive
+	// descent, bracket/slice segments and `[?(...)]` predicate filters.
+	// UpdateByPath and DeleteByPath return an error for a path containing a
+	// `..` or `[?(...)]` segment; only GetByPath accepts those.
+	GetByPath(rootNode *yaml.Node, pathExpression string) (*yaml.Node, error)
+	UpdateByPath(rootNode *yaml.Node, pathExpression string, changesToApply yaml.Node) error
+	DeleteByPath(rootNode *yaml.Node, pathExpression string) error
+	// GetAll, UpdateAll and DeleteAll apply Get/Update/Delete across a
+	// `---`-separated document stream, one document root per slice entry. An
+	// optional "d0."/"d*." prefix on path selects a single document or all of
+	// them (the default when no prefix is given).
+	GetAll(stream []*yaml.Node, path []string) ([]*yaml.Node, error)
+	UpdateAll(stream []*yaml.Node, path []string, changesToApply yaml.Node) error
+	DeleteAll(stream []*yaml.Node, path []string) error
+}
+
+// AliasBehaviour controls what Update does when the matched node is reached
+// through a YAML alias (`foo: *anchor`).
+type AliasBehaviour int
+
+const (
+	// UpdateAliasTarget mutates the anchor's underlying node in place, so every
+	// alias pointing at it observes the change. This is the default.
+	UpdateAliasTarget AliasBehaviour = iota
+	// MaterializeAlias replaces the alias with a standalone copy of the anchor's
+	// node before applying the change, leaving the anchor and its other aliases untouched.
+	MaterializeAlias
+)
+
+// CommentPosition identifies one of a yaml.Node's three comment slots.
+type CommentPosition int
+
+const (
+	HeadComment CommentPosition = iota
+	LineComment
+	FootComment
+)
+
+// UpdateOptions controls what Update overwrites on the matched node besides
+// its value. All four Preserve* flags default to true: a bare Update call
+// should never silently delete a user's comments or reset their node style.
+// PreserveHeadComment only ever reads and restores the matched node's own
+// HeadComment; for a mapping entry, yaml.v3 renders the head comment above
+// the key rather than the value Update actually matches, so it has no effect
+// there. Use SetComment/GetComments, which special-case mapping entries,
+// to manage a map key's head comment directly.
+type UpdateOptions struct {
+	PreserveHeadComment bool
+	PreserveLineComment bool
+	PreserveFootComment bool
+	PreserveStyle       bool
+	AliasBehaviour      AliasBehaviour
+	// CoerceToExisting parses changesToApply's value against the destination
+	// scalar's existing tag (when there is one) instead of adopting
+	// changesToApply's own tag outright, so writing the string "true" into a
+	// !!bool field converts rather than silently turning it into a !!str.
+	CoerceToExisting bool
+}
+
+// NewUpdateOptions returns the default, preserve-everything UpdateOptions.
+func NewUpdateOptions() UpdateOptions {
+	return UpdateOptions{
+		PreserveHeadComment: true,
+		PreserveLineComment: true,
+		PreserveFootComment: true,
+		PreserveStyle:       true,
+	}
 }
 
 type navigator struct {
 	log *logging.Logger
+	// visitedAliases tracks alias pointers seen during the current Get/Update/Delete
+	// call so that alias cycles don't send Visit into an infinite loop.
+	visitedAliases map[*yaml.Node]bool
+	// aliasBehaviour is reset at the top of every entry point (get, update,
+	// delete, SetComment) before each call, so it never leaks the behaviour
+	// requested by one call into a later, unrelated call on the same navigator.
+	aliasBehaviour AliasBehaviour
+	// createMissing controls whether recurseMap implicitly appends a missing
+	// map entry instead of just reporting no match. It's reset at the top of
+	// every entry point alongside aliasBehaviour: Update and SetComment want
+	// the historical create-on-write behaviour, but Get and GetComments must
+	// never mutate the tree they're merely reading.
+	createMissing bool
 }
 
 type VisitorFn func(*yaml.Node) error
@@ -29,9 +119,24 @@ func NewDataNavigator(l *logging.Logger) DataNavigator {
 }
 
 func (n *navigator) Get(value *yaml.Node, path []string) (*yaml.Node, error) {
+	return n.get(value, legacySegmentsFromStrings(path))
+}
+
+func (n *navigator) GetByPath(value *yaml.Node, pathExpression string) (*yaml.Node, error) {
+	segments, err := NewPathParser().ParsePath(pathExpression)
+	if err != nil {
+		return nil, err
+	}
+	return n.get(value, segments)
+}
+
+func (n *navigator) get(value *yaml.Node, segments []*PathSegment) (*yaml.Node, error) {
 	matchingNodes := make([]*yaml.Node, 0)
 
-	n.Visit(value, path, func(matchedNode *yaml.Node) error {
+	n.visitedAliases = map[*yaml.Node]bool{}
+	n.aliasBehaviour = UpdateAliasTarget
+	n.createMissing = false
+	n.Visit(value, segments, func(matchedNode *yaml.Node) error {
 		matchingNodes = append(matchingNodes, matchedNode)
 		n.log.Debug("Matched")
 		n.DebugNode(matchedNode)
@@ -50,35 +155,189 @@ func (n *navigator) Get(value *yaml.Node, path []string) (*yaml.Node, error) {
 }
 
 func (n *navigator) Update(rootNode *yaml.Node, path []string, changesToApply yaml.Node) error {
-	errorVisiting := n.Visit(rootNode, path, func(nodeToUpdate *yaml.Node) error {
+	return n.UpdateWith(rootNode, path, changesToApply, NewUpdateOptions())
+}
+
+func (n *navigator) UpdateWithAliasBehaviour(rootNode *yaml.Node, path []string, changesToApply yaml.Node, aliasBehaviour AliasBehaviour) error {
+	opts := NewUpdateOptions()
+	opts.AliasBehaviour = aliasBehaviour
+	return n.UpdateWith(rootNode, path, changesToApply, opts)
+}
+
+func (n *navigator) UpdateWith(rootNode *yaml.Node, path []string, changesToApply yaml.Node, opts UpdateOptions) error {
+	return n.update(rootNode, legacySegmentsFromStrings(path), changesToApply, opts)
+}
+
+func (n *navigator) UpdateByPath(rootNode *yaml.Node, pathExpression string, changesToApply yaml.Node) error {
+	segments, err := NewPathParser().ParsePath(pathExpression)
+	if err != nil {
+		return err
+	}
+	return n.update(rootNode, segments, changesToApply, NewUpdateOptions())
+}
+
+func (n *navigator) update(rootNode *yaml.Node, segments []*PathSegment, changesToApply yaml.Node, opts UpdateOptions) error {
+	if err := rejectReadOnlySegments(segments); err != nil {
+		return err
+	}
+	n.visitedAliases = map[*yaml.Node]bool{}
+	n.aliasBehaviour = opts.AliasBehaviour
+	n.createMissing = true
+	errorVisiting := n.Visit(rootNode, segments, func(nodeToUpdate *yaml.Node) error {
 		n.log.Debug("going to update")
 		n.DebugNode(nodeToUpdate)
 		n.log.Debug("with")
 		n.DebugNode(&changesToApply)
+		headComment, lineComment, footComment, style := nodeToUpdate.HeadComment, nodeToUpdate.LineComment, nodeToUpdate.FootComment, nodeToUpdate.Style
+		existingTag := nodeToUpdate.Tag
+		coerceToExisting := opts.CoerceToExisting && nodeToUpdate.Kind == yaml.ScalarNode &&
+			existingTag != "" && changesToApply.Kind == yaml.ScalarNode
+
+		newTag := changesToApply.Tag
+		if coerceToExisting {
+			// Validate against a throwaway node first: CoerceScalar returning an
+			// error must leave nodeToUpdate exactly as it was, not with the
+			// rejected value already applied and a mismatched tag.
+			probe := yaml.Node{Kind: yaml.ScalarNode, Value: changesToApply.Value}
+			if err := CoerceScalar(&probe, existingTag); err != nil {
+				return err
+			}
+			newTag = probe.Tag
+		}
+
 		nodeToUpdate.Value = changesToApply.Value
-		nodeToUpdate.Tag = changesToApply.Tag
+		nodeToUpdate.Tag = newTag
 		nodeToUpdate.Kind = changesToApply.Kind
 		nodeToUpdate.Style = changesToApply.Style
 		nodeToUpdate.Content = changesToApply.Content
 		nodeToUpdate.HeadComment = changesToApply.HeadComment
 		nodeToUpdate.LineComment = changesToApply.LineComment
 		nodeToUpdate.FootComment = changesToApply.FootComment
+		if opts.PreserveHeadComment {
+			nodeToUpdate.HeadComment = headComment
+		}
+		if opts.PreserveLineComment {
+			nodeToUpdate.LineComment = lineComment
+		}
+		if opts.PreserveFootComment {
+			nodeToUpdate.FootComment = footComment
+		}
+		if opts.PreserveStyle {
+			nodeToUpdate.Style = style
+		}
 		return nil
 	})
 	return errorVisiting
 }
 
+// SetComment sets a single comment on the node matched by path, without
+// touching its value, style or any other comment position.
+func (n *navigator) SetComment(rootNode *yaml.Node, path []string, position CommentPosition, text string) error {
+	segments := legacySegmentsFromStrings(path)
+	if err := rejectReadOnlySegments(segments); err != nil {
+		return err
+	}
+	n.createMissing = true
+	return n.visitCommentTarget(rootNode, segments, position, func(node *yaml.Node) error {
+		switch position {
+		case HeadComment:
+			node.HeadComment = text
+		case LineComment:
+			node.LineComment = text
+		case FootComment:
+			node.FootComment = text
+		}
+		return nil
+	})
+}
+
+// GetComments returns the comments present on the node matched by path, or
+// nil if path didn't match anything.
+func (n *navigator) GetComments(rootNode *yaml.Node, path []string) (map[CommentPosition]string, error) {
+	segments := legacySegmentsFromStrings(path)
+	matchedNode, err := n.get(rootNode, segments)
+	if err != nil {
+		return nil, err
+	}
+	if matchedNode == nil {
+		return nil, nil
+	}
+	comments := map[CommentPosition]string{
+		HeadComment: matchedNode.HeadComment,
+		LineComment: matchedNode.LineComment,
+		FootComment: matchedNode.FootComment,
+	}
+	n.createMissing = false
+	if err := n.visitCommentTarget(rootNode, segments, HeadComment, func(node *yaml.Node) error {
+		comments[HeadComment] = node.HeadComment
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// visitCommentTarget runs visitor against the node SetComment/GetComments
+// should actually read or write for position. For LineComment and
+// FootComment (and HeadComment on anything but a mapping entry) that's just
+// the node path matches, same as Get/Update. HeadComment on a mapping entry
+// is special: yaml.v3 renders it above the *key*, not the value Visit would
+// otherwise hand back, so the key node - the one just before it in the
+// parent map's Content - is targeted instead.
+func (n *navigator) visitCommentTarget(rootNode *yaml.Node, segments []*PathSegment, position CommentPosition, visitor VisitorFn) error {
+	n.visitedAliases = map[*yaml.Node]bool{}
+	n.aliasBehaviour = UpdateAliasTarget
+	if position != HeadComment || len(segments) == 0 {
+		return n.Visit(rootNode, segments, visitor)
+	}
+	lastBit, newTail := segments[len(segments)-1], segments[:len(segments)-1]
+	return n.Visit(rootNode, newTail, func(container *yaml.Node) error {
+		if container.Kind != yaml.MappingNode {
+			return n.recurse(container, lastBit, nil, visitor)
+		}
+		matches := lastBit.MatchesKey
+		if lastBit.Kind == SegmentSplat {
+			matches = func(string) bool { return true }
+		}
+		_, err := n.visitMatchingEntries(container.Content, matches, func(indexInMap int) error {
+			return visitor(container.Content[indexInMap])
+		})
+		return err
+	})
+}
+
 func (n *navigator) Delete(rootNode *yaml.Node, path []string) error {
+	return n.delete(rootNode, legacySegmentsFromStrings(path))
+}
 
-	lastBit, newTail := path[len(path)-1], path[:len(path)-1]
-	n.log.Debug("splitting path, %v", lastBit)
+func (n *navigator) DeleteByPath(rootNode *yaml.Node, pathExpression string) error {
+	segments, err := NewPathParser().ParsePath(pathExpression)
+	if err != nil {
+		return err
+	}
+	return n.delete(rootNode, segments)
+}
+
+func (n *navigator) delete(rootNode *yaml.Node, segments []*PathSegment) error {
+	if len(segments) == 0 {
+		return fmt.Errorf("cannot delete the root node: path expression is empty")
+	}
+	if err := rejectReadOnlySegments(segments); err != nil {
+		return err
+	}
+
+	lastBit, newTail := segments[len(segments)-1], segments[:len(segments)-1]
+	n.log.Debug("splitting path, %v", lastBit.Key)
 	n.log.Debug("new tail, %v", newTail)
+	n.visitedAliases = map[*yaml.Node]bool{}
+	n.aliasBehaviour = UpdateAliasTarget
+	n.createMissing = true
 	errorVisiting := n.Visit(rootNode, newTail, func(nodeToUpdate *yaml.Node) error {
-		n.log.Debug("need to find %v in here", lastBit)
+		n.log.Debug("need to find %v in here", lastBit.Key)
 		n.DebugNode(nodeToUpdate)
 		original := nodeToUpdate.Content
 		if nodeToUpdate.Kind == yaml.SequenceNode {
-			var index, err = strconv.ParseInt(lastBit, 10, 64) // nolint
+			var index, err = strconv.ParseInt(lastBit.Key, 10, 64) // nolint
 			if err != nil {
 				return err
 			}
@@ -90,7 +349,11 @@ func (n *navigator) Delete(rootNode *yaml.Node, path []string) error {
 
 		} else if nodeToUpdate.Kind == yaml.MappingNode {
 
-			_, errorVisiting := n.visitMatchingEntries(nodeToUpdate.Content, lastBit, func(indexInMap int) error {
+			matches := lastBit.MatchesKey
+			if lastBit.Kind == SegmentSplat {
+				matches = func(string) bool { return true }
+			}
+			_, errorVisiting := n.visitMatchingEntries(nodeToUpdate.Content, matches, func(indexInMap int) error {
 				nodeToUpdate.Content = append(original[:indexInMap], original[indexInMap+2:]...)
 				return nil
 			})
@@ -105,11 +368,24 @@ func (n *navigator) Delete(rootNode *yaml.Node, path []string) error {
 	return errorVisiting
 }
 
-func (n *navigator) Visit(value *yaml.Node, path []string, visitor VisitorFn) error {
-	realValue := value
+// rejectReadOnlySegments stops Update/Delete being used with a `..` recursive
+// descent or `[?(...)]` predicate filter segment: those can match an
+// unbounded, data-dependent set of nodes, so mutating through them is
+// disallowed rather than guessed at.
+func rejectReadOnlySegments(segments []*PathSegment) error {
+	for _, segment := range segments {
+		if segment.Kind == SegmentRecursive || segment.Kind == SegmentFilter {
+			return fmt.Errorf("path segment %q is read-only and cannot be used with Update or Delete", segment.String())
+		}
+	}
+	return nil
+}
+
+func (n *navigator) Visit(value *yaml.Node, path []*PathSegment, visitor VisitorFn) error {
+	realValue := n.resolveAlias(value)
 	if realValue.Kind == yaml.DocumentNode {
 		n.log.Debugf("its a document! returning the first child")
-		realValue = value.Content[0]
+		realValue = n.resolveAlias(realValue.Content[0])
 	}
 	if len(path) > 0 {
 		n.log.Debugf("diving into %v", path[0])
@@ -119,7 +395,45 @@ func (n *navigator) Visit(value *yaml.Node, path []string, visitor VisitorFn) er
 	return visitor(realValue)
 }
 
-func (n *navigator) guessKind(tail []string, guess yaml.Kind) yaml.Kind {
+// resolveAlias follows node.Alias until it reaches a non-alias node, so Get,
+// Update and Delete all see through `foo: *anchor` transparently. A cycle
+// (an alias that, directly or indirectly, points back to itself) stops the
+// chase instead of recursing forever.
+func (n *navigator) resolveAlias(value *yaml.Node) *yaml.Node {
+	if value.Kind != yaml.AliasNode {
+		return value
+	}
+	if n.visitedAliases == nil {
+		n.visitedAliases = map[*yaml.Node]bool{}
+	}
+	if n.visitedAliases[value] {
+		n.log.Debug("alias cycle detected, stopping at %v", value.Value)
+		return value
+	}
+	n.visitedAliases[value] = true
+	return n.resolveAlias(value.Alias)
+}
+
+// materializeAlias returns a standalone deep copy of the node an alias points
+// to, so updating it doesn't mutate the anchor or any other alias to it.
+func (n *navigator) materializeAlias(aliasNode *yaml.Node) *yaml.Node {
+	return n.copyNode(n.resolveAlias(aliasNode))
+}
+
+func (n *navigator) copyNode(original *yaml.Node) *yaml.Node {
+	copied := *original
+	copied.Anchor = ""
+	copied.Alias = nil
+	if original.Content != nil {
+		copied.Content = make([]*yaml.Node, len(original.Content))
+		for i, child := range original.Content {
+			copied.Content[i] = n.copyNode(child)
+		}
+	}
+	return &copied
+}
+
+func (n *navigator) guessKind(tail []*PathSegment, guess yaml.Kind) yaml.Kind {
 	n.log.Debug("tail %v", tail)
 	if len(tail) == 0 && guess == 0 {
 		n.log.Debug("end of path, must be a scalar")
@@ -128,12 +442,13 @@ func (n *navigator) guessKind(tail []string, guess yaml.Kind) yaml.Kind {
 		return guess
 	}
 
-	var _, errorParsingInt = strconv.ParseInt(tail[0], 10, 64)
-	if tail[0] == "+" || errorParsingInt == nil {
+	switch tail[0].Kind {
+	case SegmentIndex, SegmentAppend, SegmentSlice:
 		return yaml.SequenceNode
-	}
-	if tail[0] == "*" && (guess == yaml.SequenceNode || guess == yaml.MappingNode) {
-		return guess
+	case SegmentSplat:
+		if guess == yaml.SequenceNode || guess == yaml.MappingNode {
+			return guess
+		}
 	}
 	return yaml.MappingNode
 }
@@ -159,20 +474,28 @@ func (n *navigator) DebugNode(value *yaml.Node) {
 	}
 }
 
-func (n *navigator) recurse(value *yaml.Node, head string, tail []string, visitor VisitorFn) error {
+func (n *navigator) recurse(value *yaml.Node, head *PathSegment, tail []*PathSegment, visitor VisitorFn) error {
+	if head.Kind == SegmentRecursive {
+		return n.recurseDescent(value, head, tail, visitor)
+	}
 	switch value.Kind {
 	case yaml.MappingNode:
 		n.log.Debug("its a map with %v entries", len(value.Content)/2)
-		if head == "*" {
+		if head.Kind == SegmentSplat {
 			return n.splatMap(value, tail, visitor)
 		}
 		return n.recurseMap(value, head, tail, visitor)
 	case yaml.SequenceNode:
 		n.log.Debug("its a sequence of %v things!, %v", len(value.Content))
-		if head == "*" {
+		switch head.Kind {
+		case SegmentSplat:
 			return n.splatArray(value, tail, visitor)
-		} else if head == "+" {
+		case SegmentAppend:
 			return n.appendArray(value, tail, visitor)
+		case SegmentSlice:
+			return n.sliceArray(value, head, tail, visitor)
+		case SegmentFilter:
+			return n.filterArray(value, head, tail, visitor)
 		}
 		return n.recurseArray(value, head, tail, visitor)
 	default:
@@ -180,12 +503,12 @@ func (n *navigator) recurse(value *yaml.Node, head string, tail []string, visito
 	}
 }
 
-func (n *navigator) splatMap(value *yaml.Node, tail []string, visitor VisitorFn) error {
+func (n *navigator) splatMap(value *yaml.Node, tail []*PathSegment, visitor VisitorFn) error {
 	for index, content := range value.Content {
 		if index%2 == 0 {
 			continue
 		}
-		content = n.getOrReplace(content, n.guessKind(tail, content.Kind))
+		content = n.prepareChild(content, tail)
 		var err = n.Visit(content, tail, visitor)
 		if err != nil {
 			return err
@@ -194,10 +517,36 @@ func (n *navigator) splatMap(value *yaml.Node, tail []string, visitor VisitorFn)
 	return nil
 }
 
-func (n *navigator) recurseMap(value *yaml.Node, head string, tail []string, visitor VisitorFn) error {
-	visited, errorVisiting := n.visitMatchingEntries(value.Content, head, func(indexInMap int) error {
-		value.Content[indexInMap+1] = n.getOrReplace(value.Content[indexInMap+1], n.guessKind(tail, value.Content[indexInMap+1].Kind))
-		return n.Visit(value.Content[indexInMap+1], tail, visitor)
+// prepareChild gets a map entry or array element ready to recurse/visit. An
+// alias is left untouched (so the default behaviour keeps mutating the
+// anchor's node transparently via Visit) unless the caller asked to
+// materialize it into a standalone copy - which happens here, at the first
+// alias encountered on the path, regardless of whether more segments remain:
+// materializing only at the terminal segment would still land the mutation
+// on the shared anchor whenever an alias sits mid-path (e.g. `bar.a` where
+// `bar: *base`), since Visit resolves aliases before the visitor ever runs.
+func (n *navigator) prepareChild(child *yaml.Node, tail []*PathSegment) *yaml.Node {
+	if child.Kind == yaml.AliasNode {
+		if n.aliasBehaviour == MaterializeAlias {
+			return n.materializeAlias(child)
+		}
+		return child
+	}
+	if !n.createMissing {
+		// A read must never coerce an existing node to the kind the rest of
+		// the path expects - e.g. Get(["a","b"]) against `a: 1` would
+		// otherwise rewrite the scalar into an empty map just to keep
+		// descending, corrupting the tree it was only meant to inspect.
+		return child
+	}
+	return n.getOrReplace(child, n.guessKind(tail, child.Kind))
+}
+
+func (n *navigator) recurseMap(value *yaml.Node, head *PathSegment, tail []*PathSegment, visitor VisitorFn) error {
+	visited, errorVisiting := n.visitMatchingEntries(value.Content, head.MatchesKey, func(indexInMap int) error {
+		mapEntryValue := n.prepareChild(value.Content[indexInMap+1], tail)
+		value.Content[indexInMap+1] = mapEntryValue
+		return n.Visit(mapEntryValue, tail, visitor)
 	})
 
 	if errorVisiting != nil {
@@ -208,22 +557,129 @@ func (n *navigator) recurseMap(value *yaml.Node, head string, tail []string, vis
 		return nil
 	}
 
+	if head.Key != "<<" && !n.createMissing {
+		// Merge-key lookup is read-only: a Get on a merged key returns the
+		// effective value, but writing through "<<" would mutate the anchor's
+		// node and corrupt every other map merging the same anchor in. Update
+		// and Delete skip straight past this to the direct map instead, so a
+		// write either hits an existing local entry or creates one.
+		mergeVisited, errorVisitingMerge := n.visitMergedEntries(value, head, tail, visitor)
+		if errorVisitingMerge != nil {
+			return errorVisitingMerge
+		}
+		if mergeVisited {
+			return nil
+		}
+	}
+
+	if head.Kind != SegmentKey && head.Kind != SegmentIndex {
+		// splat/slice/filter/recursive segments never implicitly create new entries;
+		// a numeric SegmentIndex still does here, since against a MappingNode it's
+		// just a literal, all-digit key rather than a sequence position.
+		return nil
+	}
+
+	if !n.createMissing {
+		// Get/GetComments must never mutate the tree they're only reading.
+		return nil
+	}
+
 	//didn't find it, lets add it.
-	value.Content = append(value.Content, &yaml.Node{Value: head, Kind: yaml.ScalarNode})
+	value.Content = append(value.Content, &yaml.Node{Value: head.Raw, Kind: yaml.ScalarNode})
 	mapEntryValue := yaml.Node{Kind: n.guessKind(tail, 0)}
 	value.Content = append(value.Content, &mapEntryValue)
 	n.log.Debug("adding new node %v", value.Content)
-	return n.Visit(&mapEntryValue, tail, visitor)
+	if err := n.Visit(&mapEntryValue, tail, visitor); err != nil {
+		return err
+	}
+	resolveFreshScalarTag(&mapEntryValue)
+	return nil
+}
+
+// visitMergedEntries implements YAML merge key (`<<: *base`) lookups: a key
+// not found directly on the map is searched for in whatever the `<<` entry
+// points to (a single map alias, or a sequence of them), so that, for
+// instance, Get on a merged key returns the effective value. Direct entries
+// are always checked first by recurseMap, so local overrides win.
+func (n *navigator) visitMergedEntries(value *yaml.Node, head *PathSegment, tail []*PathSegment, visitor VisitorFn) (bool, error) {
+	visited := false
+	for index, content := range value.Content {
+		if index%2 != 0 || content.Value != "<<" {
+			continue
+		}
+		for _, mergedMap := range n.flattenMergeSources(value.Content[index+1]) {
+			mergeVisited, errorVisiting := n.visitMatchingEntries(mergedMap.Content, head.MatchesKey, func(indexInMap int) error {
+				mergedMap.Content[indexInMap+1] = n.prepareChild(mergedMap.Content[indexInMap+1], tail)
+				return n.Visit(mergedMap.Content[indexInMap+1], tail, visitor)
+			})
+			if errorVisiting != nil {
+				return visited, errorVisiting
+			}
+			if mergeVisited {
+				visited = true
+			}
+		}
+	}
+	return visited, nil
+}
+
+// recurseDescent implements `..name`: it walks every descendant of value,
+// visiting (and, for map entries, recursing into) any node reached via a
+// key matching head, while continuing to descend everywhere else too. value
+// is resolved through any alias first, so descent keeps going into a node
+// reached only via `foo: *anchor` instead of stopping dead at the alias.
+func (n *navigator) recurseDescent(value *yaml.Node, head *PathSegment, tail []*PathSegment, visitor VisitorFn) error {
+	value = n.resolveAlias(value)
+	switch value.Kind {
+	case yaml.MappingNode:
+		for index := 0; index+1 < len(value.Content); index += 2 {
+			keyNode := value.Content[index]
+			childValue := n.prepareChild(value.Content[index+1], nil)
+			value.Content[index+1] = childValue
+			if head.MatchesKey(keyNode.Value) {
+				if err := n.Visit(childValue, tail, visitor); err != nil {
+					return err
+				}
+			}
+			if err := n.recurseDescent(childValue, head, tail, visitor); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for index, childValue := range value.Content {
+			resolved := n.prepareChild(childValue, nil)
+			value.Content[index] = resolved
+			if err := n.recurseDescent(resolved, head, tail, visitor); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flattenMergeSources resolves the value of a `<<` entry into the list of
+// mapping nodes it merges in, handling both `<<: *base` and `<<: [*a, *b]`.
+func (n *navigator) flattenMergeSources(mergeValue *yaml.Node) []*yaml.Node {
+	resolved := n.resolveAlias(mergeValue)
+	if resolved.Kind == yaml.SequenceNode {
+		sources := make([]*yaml.Node, 0, len(resolved.Content))
+		for _, item := range resolved.Content {
+			sources = append(sources, n.resolveAlias(item))
+		}
+		return sources
+	}
+	return []*yaml.Node{resolved}
 }
 
 type mapVisitorFn func(int) error
+type keyMatcherFn func(actual string) bool
 
-func (n *navigator) visitMatchingEntries(contents []*yaml.Node, key string, visit mapVisitorFn) (bool, error) {
+func (n *navigator) visitMatchingEntries(contents []*yaml.Node, matches keyMatcherFn, visit mapVisitorFn) (bool, error) {
 	visited := false
 	for index, content := range contents {
 		// value.Content is a concatenated array of key, value,
 		// so keys are in the even indexes, values in odd.
-		if index%2 == 0 && (n.matchesKey(key, content.Value)) {
+		if index%2 == 0 && matches(content.Value) {
 			errorVisiting := visit(index)
 			if errorVisiting != nil {
 				return visited, errorVisiting
@@ -234,19 +690,11 @@ func (n *navigator) visitMatchingEntries(contents []*yaml.Node, key string, visi
 	return visited, nil
 }
 
-func (n *navigator) matchesKey(key string, actual string) bool {
-	var prefixMatch = strings.TrimSuffix(key, "*")
-	if prefixMatch != key {
-		return strings.HasPrefix(actual, prefixMatch)
-	}
-	return actual == key
-}
-
-func (n *navigator) splatArray(value *yaml.Node, tail []string, visitor VisitorFn) error {
+func (n *navigator) splatArray(value *yaml.Node, tail []*PathSegment, visitor VisitorFn) error {
 	for _, childValue := range value.Content {
 		n.log.Debug("processing")
 		n.DebugNode(childValue)
-		childValue = n.getOrReplace(childValue, n.guessKind(tail, childValue.Kind))
+		childValue = n.prepareChild(childValue, tail)
 		var err = n.Visit(childValue, tail, visitor)
 		if err != nil {
 			return err
@@ -255,25 +703,68 @@ func (n *navigator) splatArray(value *yaml.Node, tail []string, visitor VisitorF
 	return nil
 }
 
-func (n *navigator) appendArray(value *yaml.Node, tail []string, visitor VisitorFn) error {
+func (n *navigator) appendArray(value *yaml.Node, tail []*PathSegment, visitor VisitorFn) error {
 	var newNode = yaml.Node{Kind: n.guessKind(tail, 0)}
 	value.Content = append(value.Content, &newNode)
 	n.log.Debug("appending a new node, %v", value.Content)
-	return n.Visit(&newNode, tail, visitor)
+	if err := n.Visit(&newNode, tail, visitor); err != nil {
+		return err
+	}
+	resolveFreshScalarTag(&newNode)
+	return nil
 }
 
-func (n *navigator) recurseArray(value *yaml.Node, head string, tail []string, visitor VisitorFn) error {
-	var index, err = strconv.ParseInt(head, 10, 64) // nolint
+func (n *navigator) recurseArray(value *yaml.Node, head *PathSegment, tail []*PathSegment, visitor VisitorFn) error {
+	var index, err = strconv.ParseInt(head.Key, 10, 64) // nolint
 	if err != nil {
 		return err
 	}
 	if index >= int64(len(value.Content)) {
 		return nil
 	}
-	value.Content[index] = n.getOrReplace(value.Content[index], n.guessKind(tail, value.Content[index].Kind))
+	value.Content[index] = n.prepareChild(value.Content[index], tail)
 	return n.Visit(value.Content[index], tail, visitor)
 }
 
+// sliceArray implements the `[start:end]` segment, visiting every element in
+// [start, end). A missing/out-of-range end means "to the end of the array".
+func (n *navigator) sliceArray(value *yaml.Node, head *PathSegment, tail []*PathSegment, visitor VisitorFn) error {
+	length := len(value.Content)
+	start := head.SliceStart
+	if start < 0 {
+		start = 0
+	} else if start > length {
+		start = length
+	}
+	end := head.SliceEnd
+	if end < 0 || end > length {
+		end = length
+	}
+	for index := start; index < end; index++ {
+		value.Content[index] = n.prepareChild(value.Content[index], tail)
+		if err := n.Visit(value.Content[index], tail, visitor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterArray implements the `[?(.key==value)]` predicate segment, visiting
+// every sequence element whose child scalar at FilterKey satisfies FilterOp.
+func (n *navigator) filterArray(value *yaml.Node, head *PathSegment, tail []*PathSegment, visitor VisitorFn) error {
+	for index, childValue := range value.Content {
+		resolved := n.resolveAlias(childValue)
+		if !head.MatchesFilter(resolved) {
+			continue
+		}
+		value.Content[index] = n.prepareChild(childValue, tail)
+		if err := n.Visit(value.Content[index], tail, visitor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // func entriesInSlice(context yaml.MapSlice, key string) []*yaml.MapItem {
 // 	var matches = make([]*yaml.MapItem, 0)
 // 	for idx := range context {
@@ -602,4 +1093,4 @@ func (n *navigator) recurseArray(value *yaml.Node, head string, tail []string, v
 // 		return deleteArray(child, remainingPaths, index)
 // 	}
 // 	return child, nil
-// }
\ No newline at end of file
+// }
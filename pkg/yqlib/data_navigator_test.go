@@ -0,0 +1,293 @@
+package yqlib
+
+import (
+	"testing"
+	"time"
+
+	logging "gopkg.in/op/go-logging.v1"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func testLogger() *logging.Logger {
+	return logging.MustGetLogger("yqlib-test")
+}
+
+func parseDocument(t *testing.T, content string) *yaml.Node {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		t.Fatalf("failed to parse test document: %v", err)
+	}
+	return &doc
+}
+
+func TestGetFollowsNestedAliases(t *testing.T) {
+	doc := parseDocument(t, `
+base: &base
+  a: 1
+  nested: &nested
+    b: 2
+foo: *base
+`)
+	nav := NewDataNavigator(testLogger())
+
+	matched, err := nav.Get(doc, []string{"foo", "nested", "b"})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if matched == nil || matched.Value != "2" {
+		t.Fatalf("expected Get through nested aliases to find \"2\", got %+v", matched)
+	}
+}
+
+func TestUpdateAliasTargetMutatesAnchor(t *testing.T) {
+	doc := parseDocument(t, `
+base: &base
+  a: 1
+bar: *base
+baz: *base
+`)
+	nav := NewDataNavigator(testLogger())
+
+	err := nav.Update(doc, []string{"bar", "a"}, yaml.Node{Kind: yaml.ScalarNode, Value: "99", Tag: "!!int"})
+	if err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	matched, err := nav.Get(doc, []string{"baz", "a"})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if matched == nil || matched.Value != "99" {
+		t.Fatalf("expected the default UpdateAliasTarget behaviour to update the shared anchor, got %+v", matched)
+	}
+}
+
+func TestUpdateMaterializeAliasLeavesOtherAliasesUntouched(t *testing.T) {
+	doc := parseDocument(t, `
+base: &base
+  a: 1
+bar: *base
+baz: *base
+`)
+	nav := NewDataNavigator(testLogger())
+
+	err := nav.UpdateWithAliasBehaviour(doc, []string{"bar", "a"}, yaml.Node{Kind: yaml.ScalarNode, Value: "99", Tag: "!!int"}, MaterializeAlias)
+	if err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	matched, err := nav.Get(doc, []string{"baz", "a"})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if matched == nil || matched.Value != "1" {
+		t.Fatalf("expected MaterializeAlias to leave the other alias's view of the anchor unchanged, got %+v", matched)
+	}
+}
+
+// mapEntryValueNode returns the value node of key in root's top-level
+// mapping, inspecting root's Content directly rather than going through
+// Get - Visit always resolves aliases before a visitor sees a node, so Get
+// can never hand back an *yaml.Node with Kind == yaml.AliasNode even when
+// the underlying document still has one.
+func mapEntryValueNode(t *testing.T, root *yaml.Node, key string) *yaml.Node {
+	t.Helper()
+	mapping := root
+	if mapping.Kind == yaml.DocumentNode {
+		mapping = mapping.Content[0]
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	t.Fatalf("key %q not found in mapping", key)
+	return nil
+}
+
+// A single navigator is reused across calls; a Get that happens to traverse
+// an alias must never pick up the MaterializeAlias behaviour requested by an
+// earlier, unrelated Update on the same navigator instance.
+func TestAliasBehaviourDoesNotLeakIntoLaterGet(t *testing.T) {
+	doc := parseDocument(t, `
+base: &base
+  a: 1
+bar: *base
+baz: *base
+`)
+	nav := NewDataNavigator(testLogger())
+
+	if err := nav.UpdateWithAliasBehaviour(doc, []string{"bar", "a"}, yaml.Node{Kind: yaml.ScalarNode, Value: "2", Tag: "!!int"}, MaterializeAlias); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	if kind := mapEntryValueNode(t, doc, "baz").Kind; kind != yaml.AliasNode {
+		t.Fatalf("expected baz to still be an alias before the plain Get, got kind %v", kind)
+	}
+
+	// Without the aliasBehaviour reset at the top of get(), this call would
+	// inherit the MaterializeAlias left over from the Update above and
+	// materialize baz's alias into a standalone copy as a side effect.
+	if _, err := nav.Get(doc, []string{"baz", "a"}); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+
+	if kind := mapEntryValueNode(t, doc, "baz").Kind; kind != yaml.AliasNode {
+		t.Fatalf("plain Get materialized baz's alias as a side effect, got kind %v", kind)
+	}
+}
+
+func TestGetMergeKeyPrecedence(t *testing.T) {
+	doc := parseDocument(t, `
+base: &base
+  a: 1
+  b: 2
+obj:
+  <<: *base
+  b: 99
+`)
+	nav := NewDataNavigator(testLogger())
+
+	fromMerge, err := nav.Get(doc, []string{"obj", "a"})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if fromMerge == nil || fromMerge.Value != "1" {
+		t.Fatalf("expected merge key to supply obj.a = 1, got %+v", fromMerge)
+	}
+
+	override, err := nav.Get(doc, []string{"obj", "b"})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if override == nil || override.Value != "99" {
+		t.Fatalf("expected the local entry to win over the merged one for obj.b, got %+v", override)
+	}
+}
+
+func TestUpdateThroughMergeKeySetsLocalOverrideNotAnchor(t *testing.T) {
+	doc := parseDocument(t, `
+base: &base
+  a: 1
+objA:
+  <<: *base
+objB:
+  <<: *base
+`)
+	nav := NewDataNavigator(testLogger())
+
+	if err := nav.Update(doc, []string{"objA", "a"}, yaml.Node{Kind: yaml.ScalarNode, Value: "99", Tag: "!!int"}); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	objAValue, err := nav.Get(doc, []string{"objA", "a"})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if objAValue == nil || objAValue.Value != "99" {
+		t.Fatalf("expected objA.a to be updated to 99, got %+v", objAValue)
+	}
+
+	objBValue, err := nav.Get(doc, []string{"objB", "a"})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if objBValue == nil || objBValue.Value != "1" {
+		t.Fatalf("Update through a merge key mutated the shared anchor, objB.a became %+v", objBValue)
+	}
+}
+
+func TestGetOnMismatchedKindLeavesTreeUnchanged(t *testing.T) {
+	input := "a: 1\n"
+	doc := parseDocument(t, input)
+	nav := NewDataNavigator(testLogger())
+
+	match, err := nav.Get(doc, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if match != nil {
+		t.Fatalf("expected no match descending into a scalar, got %+v", match)
+	}
+
+	if got := encodeDocument(t, doc); got != input {
+		t.Fatalf("Get coerced the scalar it couldn't descend into:\n got:  %q\n want: %q", got, input)
+	}
+}
+
+func TestDeleteByPathRejectsEmptyPath(t *testing.T) {
+	doc := parseDocument(t, "a: 1\n")
+	nav := NewDataNavigator(testLogger())
+
+	if err := nav.DeleteByPath(doc, ""); err == nil {
+		t.Fatal("expected DeleteByPath(\"\") to return an error instead of panicking")
+	}
+}
+
+func TestGetRecursiveDescentFollowsAliases(t *testing.T) {
+	doc := parseDocument(t, `
+base: &base
+  name: widget
+items:
+  - *base
+`)
+	nav := NewDataNavigator(testLogger())
+
+	matched, err := nav.GetByPath(doc, "..name")
+	if err != nil {
+		t.Fatalf("GetByPath returned error: %v", err)
+	}
+	if matched == nil {
+		t.Fatal("expected recursive descent to find \"name\" through the alias in items, got no match")
+	}
+}
+
+// resolveAlias tracks visited alias pointers per call, so a mutual alias
+// cycle (anchor a aliasing anchor b which in turn aliases anchor a) must
+// terminate instead of recursing forever.
+func TestResolveAliasDetectsCycle(t *testing.T) {
+	nodeA := &yaml.Node{Kind: yaml.AliasNode, Anchor: "a"}
+	nodeB := &yaml.Node{Kind: yaml.AliasNode, Anchor: "b"}
+	nodeA.Alias = nodeB
+	nodeB.Alias = nodeA
+
+	nav := NewDataNavigator(testLogger()).(*navigator)
+
+	done := make(chan *yaml.Node, 1)
+	go func() {
+		done <- nav.resolveAlias(nodeA)
+	}()
+
+	select {
+	case resolved := <-done:
+		if resolved == nil {
+			t.Fatal("resolveAlias returned nil for a cyclic alias")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("resolveAlias did not terminate on a mutual alias cycle")
+	}
+}
+
+// A rejected CoerceToExisting coercion must leave the node exactly as it
+// was, not with changesToApply's value already applied under a mismatched
+// tag.
+func TestUpdateCoerceToExistingLeavesNodeUnchangedOnRejection(t *testing.T) {
+	doc := parseDocument(t, "flag: true\n")
+	nav := NewDataNavigator(testLogger())
+
+	opts := NewUpdateOptions()
+	opts.CoerceToExisting = true
+	err := nav.UpdateWith(doc, []string{"flag"}, yaml.Node{Kind: yaml.ScalarNode, Value: "notabool", Tag: "!!str"}, opts)
+	if err == nil {
+		t.Fatal("expected coercing \"notabool\" into a !!bool field to fail")
+	}
+
+	matched, getErr := nav.Get(doc, []string{"flag"})
+	if getErr != nil {
+		t.Fatalf("Get returned error: %v", getErr)
+	}
+	if matched.Value != "true" || matched.Tag != "!!bool" {
+		t.Fatalf("expected the node to be left unchanged after a rejected coercion, got value=%q tag=%v", matched.Value, matched.Tag)
+	}
+}
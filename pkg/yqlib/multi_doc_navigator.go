@@ -0,0 +1,101 @@
+package yqlib
+
+import (
+	"strconv"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DocumentSelector picks which document(s) of a `---`-separated stream
+// GetAll/UpdateAll/DeleteAll should operate on.
+type DocumentSelector struct {
+	All   bool
+	Index int
+}
+
+// matches reports whether the document at the given zero-based position in
+// the stream should be visited.
+func (d DocumentSelector) matches(index int) bool {
+	return d.All || d.Index == index
+}
+
+// splitDocumentSelector reads an optional leading "d0." (a single document)
+// or "d*." (every document, also the default with no prefix at all) segment
+// off path, returning the selector and the remaining path to apply per-document.
+func splitDocumentSelector(path []string) (DocumentSelector, []string) {
+	if len(path) == 0 {
+		return DocumentSelector{All: true}, path
+	}
+	if selector, ok := parseDocumentSelector(path[0]); ok {
+		return selector, path[1:]
+	}
+	return DocumentSelector{All: true}, path
+}
+
+func parseDocumentSelector(token string) (DocumentSelector, bool) {
+	if len(token) < 2 || token[0] != 'd' {
+		return DocumentSelector{}, false
+	}
+	rest := token[1:]
+	if rest == "*" {
+		return DocumentSelector{All: true}, true
+	}
+	index, err := strconv.Atoi(rest)
+	if err != nil {
+		return DocumentSelector{}, false
+	}
+	return DocumentSelector{Index: index}, true
+}
+
+// GetAll runs Get against every document in stream selected by path's
+// optional "dN."/"d*." prefix, aggregating the matches found in each one.
+// Each document is navigated independently, so nothing past the first
+// document is lost the way a single Get against a merged tree would lose it.
+func (n *navigator) GetAll(stream []*yaml.Node, path []string) ([]*yaml.Node, error) {
+	selector, remaining := splitDocumentSelector(path)
+	matches := make([]*yaml.Node, 0)
+	for docIndex, doc := range stream {
+		if !selector.matches(docIndex) {
+			continue
+		}
+		matchedNode, err := n.Get(doc, remaining)
+		if err != nil {
+			return nil, err
+		}
+		if matchedNode != nil {
+			matches = append(matches, matchedNode)
+		}
+	}
+	return matches, nil
+}
+
+// UpdateAll runs Update against every selected document in stream. Each
+// document keeps its own HeadComment/FootComment and directives, since
+// Update only ever mutates the content under doc - the document node itself
+// is never replaced.
+func (n *navigator) UpdateAll(stream []*yaml.Node, path []string, changesToApply yaml.Node) error {
+	selector, remaining := splitDocumentSelector(path)
+	for docIndex, doc := range stream {
+		if !selector.matches(docIndex) {
+			continue
+		}
+		if err := n.Update(doc, remaining, changesToApply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteAll runs Delete against every selected document in stream.
+func (n *navigator) DeleteAll(stream []*yaml.Node, path []string) error {
+	selector, remaining := splitDocumentSelector(path)
+	for docIndex, doc := range stream {
+		if !selector.matches(docIndex) {
+			continue
+		}
+		if err := n.Delete(doc, remaining); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package yqlib
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func parseDocumentStream(t *testing.T, content string) []*yaml.Node {
+	t.Helper()
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("failed to decode document stream: %v", err)
+		}
+		docs = append(docs, &doc)
+	}
+	return docs
+}
+
+const threeDocumentStream = `
+name: first
+---
+name: second
+---
+name: third
+`
+
+func TestGetAllWithNoSelectorMatchesEveryDocument(t *testing.T) {
+	stream := parseDocumentStream(t, threeDocumentStream)
+	nav := NewDataNavigator(testLogger())
+
+	matches, err := nav.GetAll(stream, []string{"name"})
+	if err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+	if len(matches) != 3 {
+		t.Fatalf("expected 3 matches across the stream, got %d", len(matches))
+	}
+	for i, want := range []string{"first", "second", "third"} {
+		if matches[i].Value != want {
+			t.Fatalf("match %d: expected %q, got %q", i, want, matches[i].Value)
+		}
+	}
+}
+
+func TestGetAllWithExplicitAllSelector(t *testing.T) {
+	stream := parseDocumentStream(t, threeDocumentStream)
+	nav := NewDataNavigator(testLogger())
+
+	matches, err := nav.GetAll(stream, []string{"d*", "name"})
+	if err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+	if len(matches) != 3 {
+		t.Fatalf("expected the d*. selector to match every document, got %d", len(matches))
+	}
+}
+
+func TestGetAllWithDocumentIndexSelector(t *testing.T) {
+	stream := parseDocumentStream(t, threeDocumentStream)
+	nav := NewDataNavigator(testLogger())
+
+	matches, err := nav.GetAll(stream, []string{"d1", "name"})
+	if err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Value != "second" {
+		t.Fatalf("expected the d1. selector to match only the second document, got %+v", matches)
+	}
+}
+
+func TestUpdateAllUpdatesOnlyTheSelectedDocument(t *testing.T) {
+	stream := parseDocumentStream(t, threeDocumentStream)
+	nav := NewDataNavigator(testLogger())
+
+	err := nav.UpdateAll(stream, []string{"d2", "name"}, yaml.Node{Kind: yaml.ScalarNode, Value: "updated", Tag: "!!str"})
+	if err != nil {
+		t.Fatalf("UpdateAll returned error: %v", err)
+	}
+
+	matches, err := nav.GetAll(stream, []string{"name"})
+	if err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+	want := []string{"first", "second", "updated"}
+	for i, expected := range want {
+		if matches[i].Value != expected {
+			t.Fatalf("document %d: expected %q after UpdateAll, got %q", i, expected, matches[i].Value)
+		}
+	}
+}
+
+func TestDeleteAllDeletesFromEveryDocument(t *testing.T) {
+	stream := parseDocumentStream(t, threeDocumentStream)
+	nav := NewDataNavigator(testLogger())
+
+	if err := nav.DeleteAll(stream, []string{"name"}); err != nil {
+		t.Fatalf("DeleteAll returned error: %v", err)
+	}
+
+	matches, err := nav.GetAll(stream, []string{"name"})
+	if err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("expected DeleteAll to remove \"name\" from every document, got %d leftover matches", len(matches))
+	}
+}
+
+func TestParseDocumentSelector(t *testing.T) {
+	cases := []struct {
+		token     string
+		wantOK    bool
+		wantAll   bool
+		wantIndex int
+	}{
+		{"d0", true, false, 0},
+		{"d7", true, false, 7},
+		{"d*", true, true, 0},
+		{"dx", false, false, 0},
+		{"d", false, false, 0},
+		{"foo", false, false, 0},
+	}
+	for _, c := range cases {
+		selector, ok := parseDocumentSelector(c.token)
+		if ok != c.wantOK {
+			t.Fatalf("parseDocumentSelector(%q): expected ok=%v, got %v", c.token, c.wantOK, ok)
+		}
+		if !ok {
+			continue
+		}
+		if selector.All != c.wantAll || selector.Index != c.wantIndex {
+			t.Fatalf("parseDocumentSelector(%q): expected {All:%v Index:%v}, got %+v", c.token, c.wantAll, c.wantIndex, selector)
+		}
+	}
+}
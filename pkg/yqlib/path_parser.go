@@ -0,0 +1,325 @@
+package yqlib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// PathSegmentKind identifies what a PathSegment matches against.
+type PathSegmentKind int
+
+const (
+	// SegmentKey matches a map entry by key, optionally as a `prefix*` match.
+	SegmentKey PathSegmentKind = iota
+	// SegmentIndex matches a single sequence element by position.
+	SegmentIndex
+	// SegmentSplat (`*`) matches every entry of a map or sequence.
+	SegmentSplat
+	// SegmentAppend (`+`) creates and matches a new sequence element.
+	SegmentAppend
+	// SegmentSlice (`[start:end]`) matches a contiguous run of sequence elements.
+	SegmentSlice
+	// SegmentFilter (`[?(.key==value)]`) matches sequence elements whose child
+	// scalar satisfies a comparison.
+	SegmentFilter
+	// SegmentRecursive (`..`) matches every descendant reached via the key
+	// carried on the segment, at any depth.
+	SegmentRecursive
+)
+
+// PathSegment is one compiled step of a path expression. Which fields are
+// meaningful depends on Kind - see the PathSegmentKind docs above.
+type PathSegment struct {
+	Kind        PathSegmentKind
+	Key         string // SegmentKey, SegmentIndex and SegmentRecursive: the key/index to match
+	Raw         string // SegmentKey and SegmentIndex: the original token, used verbatim when implicitly creating a new map entry
+	PrefixMatch bool   // SegmentKey: Key is a `prefix*` match rather than an exact one
+	SliceStart  int    // SegmentSlice
+	SliceEnd    int    // SegmentSlice, -1 means "to the end"
+	FilterKey   string // SegmentFilter: the child key to compare, e.g. "status"
+	FilterOp    string // SegmentFilter: one of == != > < >= <=
+	FilterValue string // SegmentFilter: the literal to compare against, e.g. `"active"` or `3`
+}
+
+// MatchesKey reports whether a map entry's key satisfies this segment. It's
+// only meaningful for SegmentKey, SegmentRecursive and SegmentIndex segments
+// (the last matched against a map as a literal, all-digit key).
+func (s *PathSegment) MatchesKey(actual string) bool {
+	if s.PrefixMatch {
+		return strings.HasPrefix(actual, s.Key)
+	}
+	return actual == s.Key
+}
+
+// MatchesFilter evaluates this SegmentFilter segment against a sequence
+// element: it looks up FilterKey amongst candidate's direct map entries and
+// compares the scalar found there against FilterValue using FilterOp.
+func (s *PathSegment) MatchesFilter(candidate *yaml.Node) bool {
+	if candidate == nil || candidate.Kind != yaml.MappingNode {
+		return false
+	}
+	for index := 0; index+1 < len(candidate.Content); index += 2 {
+		if candidate.Content[index].Value != s.FilterKey {
+			continue
+		}
+		return compareScalar(candidate.Content[index+1], s.FilterOp, s.FilterValue)
+	}
+	return false
+}
+
+// String renders the segment roughly as it would appear in a path expression,
+// for use in error messages.
+func (s *PathSegment) String() string {
+	switch s.Kind {
+	case SegmentRecursive:
+		return ".." + s.Key
+	case SegmentFilter:
+		return fmt.Sprintf("[?(.%v%v%v)]", s.FilterKey, s.FilterOp, s.FilterValue)
+	case SegmentSlice:
+		return fmt.Sprintf("[%v:%v]", s.SliceStart, s.SliceEnd)
+	case SegmentSplat:
+		return "*"
+	case SegmentAppend:
+		return "+"
+	default:
+		return s.Key
+	}
+}
+
+func compareScalar(node *yaml.Node, op string, literal string) bool {
+	if quoted, ok := unquoteLiteral(literal); ok {
+		switch op {
+		case "==":
+			return node.Value == quoted
+		case "!=":
+			return node.Value != quoted
+		default:
+			return false
+		}
+	}
+
+	nodeNum, nodeErr := strconv.ParseFloat(node.Value, 64)
+	literalNum, literalErr := strconv.ParseFloat(literal, 64)
+	if nodeErr == nil && literalErr == nil {
+		switch op {
+		case "==":
+			return nodeNum == literalNum
+		case "!=":
+			return nodeNum != literalNum
+		case ">":
+			return nodeNum > literalNum
+		case "<":
+			return nodeNum < literalNum
+		case ">=":
+			return nodeNum >= literalNum
+		case "<=":
+			return nodeNum <= literalNum
+		}
+		return false
+	}
+
+	switch op {
+	case "==":
+		return node.Value == literal
+	case "!=":
+		return node.Value != literal
+	default:
+		return false
+	}
+}
+
+func unquoteLiteral(literal string) (string, bool) {
+	if len(literal) >= 2 && literal[0] == '"' && literal[len(literal)-1] == '"' {
+		return literal[1 : len(literal)-1], true
+	}
+	return "", false
+}
+
+// PathParser compiles a YAMLPath/JSONPath-flavoured path expression (e.g.
+// `a.b[1:3]..c[?(.status=="active")]`) into a []*PathSegment that
+// navigator.Visit can walk without any further string parsing.
+type PathParser struct{}
+
+// NewPathParser creates a PathParser. It carries no state, so a single
+// instance can be reused across calls.
+func NewPathParser() *PathParser {
+	return &PathParser{}
+}
+
+// ParsePath compiles a path expression into its constituent segments.
+func (p *PathParser) ParsePath(path string) ([]*PathSegment, error) {
+	var segments []*PathSegment
+	runes := []rune(path)
+	length := len(runes)
+	index := 0
+	for index < length {
+		switch {
+		case runes[index] == '.' && index+1 < length && runes[index+1] == '.':
+			segment, consumed, err := p.parseRecursiveTarget(runes, index+2)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+			index += 2 + consumed
+		case runes[index] == '.':
+			index++
+		case runes[index] == '[':
+			end := matchingBracket(runes, index)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '[' in path %q", path)
+			}
+			segment, err := p.parseBracketSegment(string(runes[index+1 : end]))
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+			index = end + 1
+		default:
+			start := index
+			for index < length && runes[index] != '.' && runes[index] != '[' {
+				index++
+			}
+			key := string(runes[start:index])
+			if key != "" {
+				segments = append(segments, keySegment(key))
+			}
+		}
+	}
+	return segments, nil
+}
+
+// parseRecursiveTarget reads the segment immediately following a `..`, which
+// names the key the recursive descent should match at every depth.
+func (p *PathParser) parseRecursiveTarget(runes []rune, index int) (*PathSegment, int, error) {
+	length := len(runes)
+	if index < length && runes[index] == '[' {
+		end := matchingBracket(runes, index)
+		if end < 0 {
+			return nil, 0, fmt.Errorf("unterminated '[' after '..'")
+		}
+		return &PathSegment{Kind: SegmentRecursive, Key: unquoteBracketKey(string(runes[index+1 : end]))}, end + 1 - index, nil
+	}
+	start := index
+	for index < length && runes[index] != '.' && runes[index] != '[' {
+		index++
+	}
+	return &PathSegment{Kind: SegmentRecursive, Key: string(runes[start:index])}, index - start, nil
+}
+
+func (p *PathParser) parseBracketSegment(inner string) (*PathSegment, error) {
+	trimmed := strings.TrimSpace(inner)
+	if strings.HasPrefix(trimmed, "?(") && strings.HasSuffix(trimmed, ")") {
+		return p.parseFilterExpression(trimmed[2 : len(trimmed)-1])
+	}
+	if colon := strings.Index(trimmed, ":"); colon >= 0 {
+		return p.parseSliceExpression(trimmed, colon)
+	}
+	key := unquoteBracketKey(trimmed)
+	if _, err := strconv.ParseInt(key, 10, 64); err == nil {
+		return &PathSegment{Kind: SegmentIndex, Key: key, Raw: key}, nil
+	}
+	return &PathSegment{Kind: SegmentKey, Key: key, Raw: key}, nil
+}
+
+func (p *PathParser) parseSliceExpression(trimmed string, colon int) (*PathSegment, error) {
+	startStr := strings.TrimSpace(trimmed[:colon])
+	endStr := strings.TrimSpace(trimmed[colon+1:])
+	start, end := 0, -1
+	var err error
+	if startStr != "" {
+		if start, err = strconv.Atoi(startStr); err != nil {
+			return nil, fmt.Errorf("invalid slice start %q: %v", startStr, err)
+		}
+		if start < 0 {
+			return nil, fmt.Errorf("slice start %q must not be negative", startStr)
+		}
+	}
+	if endStr != "" {
+		if end, err = strconv.Atoi(endStr); err != nil {
+			return nil, fmt.Errorf("invalid slice end %q: %v", endStr, err)
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("slice end %q must not be negative", endStr)
+		}
+	}
+	return &PathSegment{Kind: SegmentSlice, SliceStart: start, SliceEnd: end}, nil
+}
+
+func (p *PathParser) parseFilterExpression(expression string) (*PathSegment, error) {
+	expression = strings.TrimSpace(expression)
+	if !strings.HasPrefix(expression, ".") {
+		return nil, fmt.Errorf("filter expression must start with '.': %q", expression)
+	}
+	expression = expression[1:]
+	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<"} {
+		if at := strings.Index(expression, op); at >= 0 {
+			return &PathSegment{
+				Kind:        SegmentFilter,
+				FilterKey:   strings.TrimSpace(expression[:at]),
+				FilterOp:    op,
+				FilterValue: strings.TrimSpace(expression[at+len(op):]),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported filter expression: %q", expression)
+}
+
+func keySegment(key string) *PathSegment {
+	switch key {
+	case "*":
+		return &PathSegment{Kind: SegmentSplat}
+	case "+":
+		return &PathSegment{Kind: SegmentAppend}
+	}
+	if _, err := strconv.ParseInt(key, 10, 64); err == nil {
+		return &PathSegment{Kind: SegmentIndex, Key: key, Raw: key}
+	}
+	if prefix := strings.TrimSuffix(key, "*"); prefix != key {
+		return &PathSegment{Kind: SegmentKey, Key: prefix, Raw: key, PrefixMatch: true}
+	}
+	return &PathSegment{Kind: SegmentKey, Key: key, Raw: key}
+}
+
+// legacySegmentsFromStrings translates the original []string path tokens
+// (used by Get/Update/Delete) into PathSegments using the same `*`, `+`,
+// numeric-index and `prefix*` semantics those string tokens always had for
+// key matching and guessKind, so Visit can run a single segment-based
+// implementation for both the old and new path representations.
+func legacySegmentsFromStrings(path []string) []*PathSegment {
+	segments := make([]*PathSegment, len(path))
+	for i, token := range path {
+		segments[i] = keySegment(token)
+	}
+	return segments
+}
+
+func unquoteBracketKey(key string) string {
+	if len(key) >= 2 {
+		first, last := key[0], key[len(key)-1]
+		if (first == '"' && last == '"') || (first == '\'' && last == '\'') {
+			return key[1 : len(key)-1]
+		}
+	}
+	return key
+}
+
+// matchingBracket returns the index of the ']' that closes the '[' at open,
+// or -1 if there isn't one.
+func matchingBracket(runes []rune, open int) int {
+	depth := 0
+	for i := open; i < len(runes); i++ {
+		switch runes[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,175 @@
+package yqlib
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func parsePath(t *testing.T, path string) []*PathSegment {
+	t.Helper()
+	segments, err := NewPathParser().ParsePath(path)
+	if err != nil {
+		t.Fatalf("ParsePath(%q) returned error: %v", path, err)
+	}
+	return segments
+}
+
+func TestParsePathSimpleKeys(t *testing.T) {
+	segments := parsePath(t, "a.b.c")
+	if len(segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d: %+v", len(segments), segments)
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		if segments[i].Kind != SegmentKey || segments[i].Key != want || segments[i].Raw != want {
+			t.Fatalf("segment %d: expected key %q, got %+v", i, want, segments[i])
+		}
+	}
+}
+
+func TestParsePathSplatAndAppend(t *testing.T) {
+	segments := parsePath(t, "a.*.+")
+	if segments[1].Kind != SegmentSplat {
+		t.Fatalf("expected SegmentSplat, got %+v", segments[1])
+	}
+	if segments[2].Kind != SegmentAppend {
+		t.Fatalf("expected SegmentAppend, got %+v", segments[2])
+	}
+}
+
+func TestParsePathPrefixMatch(t *testing.T) {
+	segments := parsePath(t, "foo*")
+	if segments[0].Kind != SegmentKey || !segments[0].PrefixMatch || segments[0].Key != "foo" {
+		t.Fatalf("expected a prefix-match key segment \"foo\", got %+v", segments[0])
+	}
+	if !segments[0].MatchesKey("foobar") || segments[0].MatchesKey("barfoo") {
+		t.Fatalf("prefix match behaved unexpectedly for segment %+v", segments[0])
+	}
+}
+
+func TestParsePathBracketKeyWithDot(t *testing.T) {
+	segments := parsePath(t, `a["b.c"]`)
+	if len(segments) != 2 || segments[1].Kind != SegmentKey || segments[1].Key != "b.c" || segments[1].Raw != "b.c" {
+		t.Fatalf("expected bracket segment to preserve the dotted key, got %+v", segments)
+	}
+}
+
+func TestParsePathBracketIndex(t *testing.T) {
+	segments := parsePath(t, "a[2]")
+	if len(segments) != 2 || segments[1].Kind != SegmentIndex || segments[1].Key != "2" {
+		t.Fatalf("expected an index segment for \"2\", got %+v", segments)
+	}
+}
+
+func TestParsePathSlice(t *testing.T) {
+	segments := parsePath(t, "a[1:3]")
+	seg := segments[1]
+	if seg.Kind != SegmentSlice || seg.SliceStart != 1 || seg.SliceEnd != 3 {
+		t.Fatalf("expected slice [1:3], got %+v", seg)
+	}
+}
+
+func TestParsePathOpenEndedSlice(t *testing.T) {
+	segments := parsePath(t, "a[2:]")
+	seg := segments[1]
+	if seg.Kind != SegmentSlice || seg.SliceStart != 2 || seg.SliceEnd != -1 {
+		t.Fatalf("expected slice [2:-1] (open ended), got %+v", seg)
+	}
+}
+
+func TestParsePathSliceRejectsNegativeBounds(t *testing.T) {
+	if _, err := NewPathParser().ParsePath("a[1:-1]"); err == nil {
+		t.Fatal("expected a negative slice end to be rejected rather than silently clamped to the array length")
+	}
+	if _, err := NewPathParser().ParsePath("a[-1:3]"); err == nil {
+		t.Fatal("expected a negative slice start to be rejected")
+	}
+}
+
+func TestParsePathRecursiveDescent(t *testing.T) {
+	segments := parsePath(t, "..name")
+	if len(segments) != 1 || segments[0].Kind != SegmentRecursive || segments[0].Key != "name" {
+		t.Fatalf("expected a single recursive segment matching \"name\", got %+v", segments)
+	}
+}
+
+func TestParsePathRecursiveDescentBracket(t *testing.T) {
+	segments := parsePath(t, `..["a.b"]`)
+	if len(segments) != 1 || segments[0].Kind != SegmentRecursive || segments[0].Key != "a.b" {
+		t.Fatalf("expected a recursive segment matching \"a.b\", got %+v", segments)
+	}
+}
+
+func TestParsePathFilterEquals(t *testing.T) {
+	segments := parsePath(t, `items[?(.status=="active")]`)
+	filter := segments[1]
+	if filter.Kind != SegmentFilter || filter.FilterKey != "status" || filter.FilterOp != "==" || filter.FilterValue != `"active"` {
+		t.Fatalf("expected a status==\"active\" filter, got %+v", filter)
+	}
+}
+
+func TestParsePathFilterNumericComparison(t *testing.T) {
+	segments := parsePath(t, "items[?(.count>3)]")
+	filter := segments[1]
+	if filter.Kind != SegmentFilter || filter.FilterKey != "count" || filter.FilterOp != ">" || filter.FilterValue != "3" {
+		t.Fatalf("expected a count>3 filter, got %+v", filter)
+	}
+}
+
+func TestParsePathUnterminatedBracket(t *testing.T) {
+	if _, err := NewPathParser().ParsePath("a[1"); err == nil {
+		t.Fatal("expected an error for an unterminated '['")
+	}
+}
+
+func TestMatchesFilterNumericFallbackComparesAsNumbers(t *testing.T) {
+	segments := parsePath(t, "items[?(.count>3)]")
+	filter := segments[1]
+	candidate := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Value: "count"}, {Value: "10"},
+		},
+	}
+	// A naive string comparison would say "10" < "3"; the numeric fallback
+	// must compare them as numbers instead.
+	if !filter.MatchesFilter(candidate) {
+		t.Fatalf("expected count=10 to satisfy >3 numerically, got false")
+	}
+}
+
+func TestMatchesFilterStringEquality(t *testing.T) {
+	segments := parsePath(t, `items[?(.status=="active")]`)
+	filter := segments[1]
+	candidate := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Value: "status"}, {Value: "active"},
+		},
+	}
+	if !filter.MatchesFilter(candidate) {
+		t.Fatal("expected status==active to match")
+	}
+	candidate.Content[1].Value = "inactive"
+	if filter.MatchesFilter(candidate) {
+		t.Fatal("expected status==active not to match \"inactive\"")
+	}
+}
+
+// keySegment misclassifying a literal numeric key as anything other than an
+// index segment without a usable Raw broke implicit map-entry creation for
+// keys like "5" (see recurseMap's read-only-segment guard).
+func TestKeySegmentNumericKeyCarriesRawForMapCreation(t *testing.T) {
+	segment := keySegment("5")
+	if segment.Kind != SegmentIndex || segment.Raw != "5" {
+		t.Fatalf("expected a numeric key segment with Raw set for map creation, got %+v", segment)
+	}
+}
+
+func TestLegacySegmentsFromStrings(t *testing.T) {
+	segments := legacySegmentsFromStrings([]string{"a", "*", "+", "5"})
+	if segments[0].Kind != SegmentKey || segments[1].Kind != SegmentSplat ||
+		segments[2].Kind != SegmentAppend || segments[3].Kind != SegmentIndex {
+		t.Fatalf("unexpected legacy segment kinds: %+v", segments)
+	}
+}
@@ -0,0 +1,172 @@
+package yqlib
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// timestampFormats mirrors the layouts yaml.v3's decoder tries when
+// resolving an implicit !!timestamp.
+var timestampFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// CoerceScalar parses node's Value against targetTag and, if it's a valid
+// representation of that tag, sets node.Tag to it. It returns an error
+// rather than silently converting a value that doesn't fit the tag - e.g.
+// coercing "abc" to !!int fails instead of turning into 0.
+func CoerceScalar(node *yaml.Node, targetTag string) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("cannot coerce a %v node to tag %v, it's not a scalar", node.Kind, targetTag)
+	}
+	switch targetTag {
+	case "!!str":
+		node.Tag = targetTag
+		return nil
+	case "!!int":
+		if _, err := parseImplicitInt(node.Value); err != nil {
+			return fmt.Errorf("cannot coerce %q to %v: %w", node.Value, targetTag, err)
+		}
+		node.Tag = targetTag
+		return nil
+	case "!!float":
+		if _, err := parseImplicitFloat(node.Value); err != nil {
+			return fmt.Errorf("cannot coerce %q to %v: %w", node.Value, targetTag, err)
+		}
+		node.Tag = targetTag
+		return nil
+	case "!!bool":
+		if !isImplicitBool(node.Value) {
+			return fmt.Errorf("cannot coerce %q to %v", node.Value, targetTag)
+		}
+		node.Tag = targetTag
+		return nil
+	case "!!timestamp":
+		if _, err := parseTimestamp(node.Value); err != nil {
+			return fmt.Errorf("cannot coerce %q to %v: %w", node.Value, targetTag, err)
+		}
+		node.Tag = targetTag
+		return nil
+	case "!!null":
+		if !isNullValue(node.Value) {
+			return fmt.Errorf("cannot coerce %q to %v", node.Value, targetTag)
+		}
+		node.Tag = targetTag
+		return nil
+	case "!!binary":
+		if _, err := base64.StdEncoding.DecodeString(node.Value); err != nil {
+			return fmt.Errorf("cannot coerce %q to %v: %w", node.Value, targetTag, err)
+		}
+		node.Tag = targetTag
+		return nil
+	default:
+		// an unrecognised/custom tag: take it on faith, same as yaml.v3 does
+		// for explicit tags it doesn't special-case.
+		node.Tag = targetTag
+		return nil
+	}
+}
+
+// guessScalarTag resolves the implicit tag a bare scalar value would get
+// from yaml.v3's decoder, so freshly created leaves (e.g. from `+` append or
+// implicit map creation) end up with a sensible tag instead of an empty one
+// that silently behaves like !!str.
+func guessScalarTag(value string) string {
+	if isNullValue(value) {
+		return "!!null"
+	}
+	if isImplicitBool(value) {
+		return "!!bool"
+	}
+	if _, err := parseImplicitInt(value); err == nil {
+		return "!!int"
+	}
+	if _, err := parseImplicitFloat(value); err == nil {
+		return "!!float"
+	}
+	if _, err := parseTimestamp(value); err == nil {
+		return "!!timestamp"
+	}
+	return "!!str"
+}
+
+// resolveFreshScalarTag assigns a guessed implicit tag to a newly created
+// scalar leaf that Update left untagged (e.g. because changesToApply itself
+// didn't carry one).
+func resolveFreshScalarTag(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "" {
+		node.Tag = guessScalarTag(node.Value)
+	}
+}
+
+// isImplicitBool reports whether value is one of the exact literals yaml.v3's
+// decoder resolves to !!bool - a narrower set than strconv.ParseBool, which
+// also accepts "1", "0", "t", "f" and the like that yaml.v3 treats as !!int
+// or !!str instead.
+func isImplicitBool(value string) bool {
+	switch value {
+	case "true", "True", "TRUE", "false", "False", "FALSE":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseImplicitInt parses value the way yaml.v3's decoder resolves an
+// implicit !!int: base 10 by default, but also hex (0x1F), octal (0o17) and
+// binary (0b101) forms, with underscores allowed as digit separators.
+func parseImplicitInt(value string) (int64, error) {
+	return strconv.ParseInt(value, 0, 64)
+}
+
+// implicitFloatSpecials are the non-finite !!float spellings yaml.v3's
+// decoder recognises - a leading-dot form, unlike Go's own "NaN"/"Inf"/
+// "Infinity" spellings that strconv.ParseFloat accepts but yaml.v3 doesn't.
+var implicitFloatSpecials = map[string]float64{
+	".inf": math.Inf(1), ".Inf": math.Inf(1), ".INF": math.Inf(1),
+	"+.inf": math.Inf(1), "+.Inf": math.Inf(1), "+.INF": math.Inf(1),
+	"-.inf": math.Inf(-1), "-.Inf": math.Inf(-1), "-.INF": math.Inf(-1),
+	".nan": math.NaN(), ".NaN": math.NaN(), ".NAN": math.NaN(),
+}
+
+// parseImplicitFloat parses value the way yaml.v3's decoder resolves an
+// implicit !!float. Ordinary decimal/exponential forms are delegated to
+// strconv.ParseFloat, but its Go-only non-finite spellings ("NaN", "Inf",
+// "Infinity", ...) are rejected unless they're one of yaml.v3's own
+// leading-dot forms above.
+func parseImplicitFloat(value string) (float64, error) {
+	if f, ok := implicitFloatSpecials[value]; ok {
+		return f, nil
+	}
+	if strings.ContainsAny(value, "nNiI") {
+		return 0, fmt.Errorf("%q is not a yaml.v3 non-finite float literal", value)
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func isNullValue(value string) bool {
+	switch value {
+	case "", "~", "null", "Null", "NULL":
+		return true
+	default:
+		return false
+	}
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	for _, format := range timestampFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised timestamp format %q", value)
+}
@@ -0,0 +1,157 @@
+package yqlib
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+}
+
+func TestCoerceScalarInt(t *testing.T) {
+	cases := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"42", false},
+		{"-7", false},
+		{"0x1F", false},
+		{"0o17", false},
+		{"0b101", false},
+		{"1_000", false},
+		{"abc", true},
+		{"4.2", true},
+	}
+	for _, c := range cases {
+		node := scalarNode(c.value)
+		err := CoerceScalar(node, "!!int")
+		if c.wantErr && err == nil {
+			t.Errorf("CoerceScalar(%q, !!int): expected an error, got none", c.value)
+		}
+		if !c.wantErr && err != nil {
+			t.Errorf("CoerceScalar(%q, !!int): unexpected error: %v", c.value, err)
+		}
+		if !c.wantErr && node.Tag != "!!int" {
+			t.Errorf("CoerceScalar(%q, !!int): expected tag !!int, got %v", c.value, node.Tag)
+		}
+	}
+}
+
+func TestCoerceScalarBoolRestrictedToYamlV3Literals(t *testing.T) {
+	cases := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"true", false},
+		{"False", false},
+		{"TRUE", false},
+		// strconv.ParseBool accepts these, but yaml.v3 does not resolve them to
+		// !!bool - "1"/"0" are !!int, "t"/"f" are !!str.
+		{"1", true},
+		{"0", true},
+		{"t", true},
+		{"f", true},
+	}
+	for _, c := range cases {
+		node := scalarNode(c.value)
+		err := CoerceScalar(node, "!!bool")
+		if c.wantErr && err == nil {
+			t.Errorf("CoerceScalar(%q, !!bool): expected an error, got none", c.value)
+		}
+		if !c.wantErr && err != nil {
+			t.Errorf("CoerceScalar(%q, !!bool): unexpected error: %v", c.value, err)
+		}
+	}
+}
+
+func TestCoerceScalarFloatRejectsGoOnlySpellings(t *testing.T) {
+	cases := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"3.14", false},
+		{"6.022e23", false},
+		{".inf", false},
+		{"-.inf", false},
+		{".nan", false},
+		// strconv.ParseFloat accepts these Go spellings, but yaml.v3's decoder
+		// only resolves the leading-dot forms above to !!float.
+		{"NaN", true},
+		{"Inf", true},
+		{"Infinity", true},
+		{"inf", true},
+	}
+	for _, c := range cases {
+		node := scalarNode(c.value)
+		err := CoerceScalar(node, "!!float")
+		if c.wantErr && err == nil {
+			t.Errorf("CoerceScalar(%q, !!float): expected an error, got none", c.value)
+		}
+		if !c.wantErr && err != nil {
+			t.Errorf("CoerceScalar(%q, !!float): unexpected error: %v", c.value, err)
+		}
+	}
+}
+
+func TestCoerceScalarTimestamp(t *testing.T) {
+	node := scalarNode("2023-01-15T04:05:06Z")
+	if err := CoerceScalar(node, "!!timestamp"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if node.Tag != "!!timestamp" {
+		t.Fatalf("expected tag !!timestamp, got %v", node.Tag)
+	}
+
+	if err := CoerceScalar(scalarNode("not a date"), "!!timestamp"); err == nil {
+		t.Fatal("expected an error coercing a non-timestamp value")
+	}
+}
+
+func TestCoerceScalarNull(t *testing.T) {
+	for _, value := range []string{"", "~", "null", "Null", "NULL"} {
+		if err := CoerceScalar(scalarNode(value), "!!null"); err != nil {
+			t.Errorf("CoerceScalar(%q, !!null): unexpected error: %v", value, err)
+		}
+	}
+	if err := CoerceScalar(scalarNode("nope"), "!!null"); err == nil {
+		t.Fatal("expected an error coercing a non-null value")
+	}
+}
+
+func TestCoerceScalarBinary(t *testing.T) {
+	if err := CoerceScalar(scalarNode("aGVsbG8="), "!!binary"); err != nil {
+		t.Fatalf("unexpected error coercing valid base64: %v", err)
+	}
+	if err := CoerceScalar(scalarNode("not base64!!"), "!!binary"); err == nil {
+		t.Fatal("expected an error coercing invalid base64")
+	}
+}
+
+func TestCoerceScalarRejectsNonScalar(t *testing.T) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	if err := CoerceScalar(node, "!!int"); err == nil {
+		t.Fatal("expected an error coercing a non-scalar node")
+	}
+}
+
+func TestGuessScalarTagMatchesImplicitResolution(t *testing.T) {
+	cases := map[string]string{
+		"":           "!!null",
+		"true":       "!!bool",
+		"42":         "!!int",
+		"0x1F":       "!!int",
+		"3.14":       "!!float",
+		".inf":       "!!float",
+		"NaN":        "!!str",
+		"Inf":        "!!str",
+		"hello":      "!!str",
+		"2023-01-15": "!!timestamp",
+	}
+	for value, want := range cases {
+		if got := guessScalarTag(value); got != want {
+			t.Errorf("guessScalarTag(%q): expected %v, got %v", value, want, got)
+		}
+	}
+}